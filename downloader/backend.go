@@ -0,0 +1,26 @@
+package downloader
+
+// Backend is anything that can manage a set of torrent transfers: the
+// embedded anacrolix client (backend/embedded), or a remote daemon such
+// as qBittorrent (backend/qbittorrent) or Aria2 (backend/aria2) reached
+// over its own RPC API. Engine itself satisfies Backend, so the
+// embedded client can be used directly or wrapped identically to the
+// remote ones.
+type Backend interface {
+	// Add starts managing source (a magnet URI, local .torrent path, or
+	// torrent+http(s):// metainfo URL) and returns an identifier for
+	// it — an infohash for backends that expose one, or whatever the
+	// backend uses in its place.
+	Add(source string, opts AddOptions) (string, error)
+	Pause(id string) error
+	Resume(id string) error
+	Remove(id string, deleteFiles bool) error
+	// List returns a snapshot of every transfer the backend is
+	// managing. A backend that can't currently be reached should
+	// return nil rather than error, the same way Engine.restore skips
+	// sources it can't resolve.
+	List() []TorrentState
+	// Progress returns a channel that receives a TorrentState update
+	// as transfers make progress.
+	Progress() <-chan TorrentState
+}
@@ -0,0 +1,213 @@
+// Package aria2 drives a remote aria2 instance over its JSON-RPC 2.0
+// interface instead of managing torrents in-process, so Cactus can be
+// pointed at an aria2 daemon that's already running elsewhere.
+package aria2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomiok/cactus-app/downloader"
+)
+
+// Config holds the connection details for an aria2 JSON-RPC endpoint.
+type Config struct {
+	// RPCURL is the JSON-RPC endpoint, e.g. "http://localhost:6800/jsonrpc".
+	RPCURL string
+	// Secret is the value configured with aria2's --rpc-secret flag, if
+	// any. It's sent as the "token:<secret>" RPC parameter aria2 expects.
+	Secret string
+}
+
+// Backend manages downloads on a remote aria2 instance over JSON-RPC.
+type Backend struct {
+	cfg    Config
+	client *http.Client
+	poller *downloader.Poller
+}
+
+// New returns a Backend for the aria2 instance described by cfg.
+func New(cfg Config) *Backend {
+	b := &Backend{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	b.poller = downloader.NewPoller(time.Second, b.List)
+	return b
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      string        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// call invokes method over JSON-RPC, prepending the auth token aria2
+// expects as the first parameter, and decodes the result into out.
+func (b *Backend) call(method string, params []interface{}, out interface{}) error {
+	params = append([]interface{}{"token:" + b.cfg.Secret}, params...)
+
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: "cactus", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.Post(b.cfg.RPCURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("aria2 %s failed: %w", method, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("aria2 %s: failed to decode response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("aria2 %s: %s", method, rpcResp.Error.Message)
+	}
+
+	if out != nil {
+		return json.Unmarshal(rpcResp.Result, out)
+	}
+	return nil
+}
+
+// Add submits source (a magnet URI, or an HTTP(S) URL to a .torrent
+// file) to aria2 via aria2.addUri and returns the resulting GID. aria2
+// assigns a GID to every download it manages, BitTorrent or not, so
+// it's used here in place of an infohash.
+func (b *Backend) Add(source string, opts downloader.AddOptions) (string, error) {
+	uris := append([]string{source}, opts.WebSeeds...)
+
+	options := map[string]interface{}{}
+	if len(opts.Trackers) > 0 {
+		options["bt-tracker"] = strings.Join(opts.Trackers, ",")
+	}
+
+	var gid string
+	if err := b.call("aria2.addUri", []interface{}{uris, options}, &gid); err != nil {
+		return "", err
+	}
+	return gid, nil
+}
+
+// Pause pauses the download identified by gid.
+func (b *Backend) Pause(gid string) error {
+	return b.call("aria2.pause", []interface{}{gid}, nil)
+}
+
+// Resume resumes the download identified by gid.
+func (b *Backend) Resume(gid string) error {
+	return b.call("aria2.unpause", []interface{}{gid}, nil)
+}
+
+// Remove stops the download identified by gid, optionally dropping it
+// from aria2's download-result history too.
+func (b *Backend) Remove(gid string, deleteFiles bool) error {
+	if err := b.call("aria2.remove", []interface{}{gid}, nil); err != nil {
+		return err
+	}
+	if deleteFiles {
+		return b.call("aria2.removeDownloadResult", []interface{}{gid}, nil)
+	}
+	return nil
+}
+
+type statusResult struct {
+	GID             string `json:"gid"`
+	Status          string `json:"status"`
+	TotalLength     string `json:"totalLength"`
+	CompletedLength string `json:"completedLength"`
+	DownloadSpeed   string `json:"downloadSpeed"`
+	Connections     string `json:"connections"`
+	BitTorrent      struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+	} `json:"bittorrent"`
+	Files []struct {
+		Path            string `json:"path"`
+		Length          string `json:"length"`
+		CompletedLength string `json:"completedLength"`
+	} `json:"files"`
+}
+
+// List fetches every active and waiting (including paused) download
+// via aria2.tellActive/aria2.tellWaiting. It returns nil, rather than
+// an error, if the daemon can't be reached, so a transient network blip
+// doesn't interrupt the poller.
+func (b *Backend) List() []downloader.TorrentState {
+	var active, waiting []statusResult
+	if err := b.call("aria2.tellActive", []interface{}{}, &active); err != nil {
+		return nil
+	}
+	if err := b.call("aria2.tellWaiting", []interface{}{0, 1000}, &waiting); err != nil {
+		return nil
+	}
+
+	results := append(active, waiting...)
+	states := make([]downloader.TorrentState, 0, len(results))
+	for _, r := range results {
+		states = append(states, r.toState())
+	}
+	return states
+}
+
+func (r statusResult) toState() downloader.TorrentState {
+	total, _ := strconv.ParseInt(r.TotalLength, 10, 64)
+	completed, _ := strconv.ParseInt(r.CompletedLength, 10, 64)
+	rate, _ := strconv.ParseFloat(r.DownloadSpeed, 64)
+	conns, _ := strconv.Atoi(r.Connections)
+
+	status := downloader.StatusDownloading
+	switch r.Status {
+	case "paused":
+		status = downloader.StatusPaused
+	case "complete":
+		status = downloader.StatusComplete
+	}
+
+	files := make([]downloader.FileState, 0, len(r.Files))
+	for _, f := range r.Files {
+		length, _ := strconv.ParseInt(f.Length, 10, 64)
+		done, _ := strconv.ParseInt(f.CompletedLength, 10, 64)
+		files = append(files, downloader.FileState{Path: f.Path, Length: length, BytesCompleted: done})
+	}
+
+	return downloader.TorrentState{
+		InfoHash:       r.GID,
+		Name:           r.BitTorrent.Info.Name,
+		Status:         status,
+		BytesCompleted: completed,
+		TotalBytes:     total,
+		DownloadRate:   rate,
+		Seeders:        conns,
+		Files:          files,
+	}
+}
+
+// Progress returns a channel fed by a Poller over List, since aria2's
+// JSON-RPC has no push-based progress notifications over plain HTTP.
+func (b *Backend) Progress() <-chan downloader.TorrentState {
+	return b.poller.Subscribe()
+}
+
+var _ downloader.Backend = (*Backend)(nil)
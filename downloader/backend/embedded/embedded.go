@@ -0,0 +1,21 @@
+// Package embedded adapts downloader.Engine, which downloads torrents
+// in-process via anacrolix/torrent, to the downloader.Backend
+// interface, so it can be selected alongside the remote RPC backends.
+package embedded
+
+import "github.com/tomiok/cactus-app/downloader"
+
+// Backend wraps an already-running Engine. Engine already implements
+// every Backend method itself; this type exists so the embedded client
+// can be constructed and referred to the same way the remote backends
+// are.
+type Backend struct {
+	*downloader.Engine
+}
+
+// New wraps engine as a Backend.
+func New(engine *downloader.Engine) *Backend {
+	return &Backend{Engine: engine}
+}
+
+var _ downloader.Backend = (*Backend)(nil)
@@ -0,0 +1,253 @@
+// Package qbittorrent drives a remote qBittorrent instance over its Web
+// API instead of managing torrents in-process, so Cactus can be pointed
+// at a seedbox that's already running qBittorrent.
+package qbittorrent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomiok/cactus-app/downloader"
+)
+
+// Config holds the connection details for a qBittorrent Web API instance.
+type Config struct {
+	// BaseURL is the Web UI's address, e.g. "http://localhost:8080".
+	BaseURL  string
+	Username string
+	Password string
+}
+
+// Backend manages torrents on a remote qBittorrent instance, using its
+// cookie-based session auth and REST-style Web API.
+type Backend struct {
+	cfg    Config
+	client *http.Client
+	poller *downloader.Poller
+}
+
+// New logs into the qBittorrent Web API described by cfg and returns a
+// Backend for it.
+func New(cfg Config) (*Backend, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		cfg:    cfg,
+		client: &http.Client{Jar: jar, Timeout: 10 * time.Second},
+	}
+
+	if err := b.login(); err != nil {
+		return nil, err
+	}
+
+	b.poller = downloader.NewPoller(time.Second, b.List)
+
+	return b, nil
+}
+
+func (b *Backend) login() error {
+	form := url.Values{"username": {b.cfg.Username}, "password": {b.cfg.Password}}
+
+	resp, err := b.client.PostForm(b.cfg.BaseURL+"/api/v2/auth/login", form)
+	if err != nil {
+		return fmt.Errorf("qbittorrent login failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qbittorrent login failed: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+var magnetHashRegexp = regexp.MustCompile(`(?i)btih:([0-9a-f]{40}|[a-z2-7]{32})`)
+
+// Add submits source to qBittorrent via /api/v2/torrents/add. Trackers
+// and webseeds are appended as magnet query parameters so qBittorrent
+// picks them up on the initial add; this only works for magnet sources,
+// since a .torrent file's bencoded contents can't be amended in transit.
+func (b *Backend) Add(source string, opts downloader.AddOptions) (string, error) {
+	augmented := source
+	if strings.HasPrefix(source, "magnet:") {
+		augmented = withMagnetParams(source, opts.Trackers, opts.WebSeeds)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("urls", augmented); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.cfg.BaseURL+"/api/v2/torrents/add", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("qbittorrent add failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("qbittorrent add failed: status %d: %s", resp.StatusCode, body)
+	}
+
+	// qBittorrent's add endpoint doesn't return the resulting torrent,
+	// so the infohash has to be recovered from the magnet itself.
+	if m := magnetHashRegexp.FindStringSubmatch(augmented); m != nil {
+		return strings.ToLower(m[1]), nil
+	}
+	return "", fmt.Errorf("qbittorrent: cannot determine infohash for non-magnet source %q", source)
+}
+
+func (b *Backend) hashAction(action, infoHash string) error {
+	form := url.Values{"hashes": {infoHash}}
+
+	resp, err := b.client.PostForm(fmt.Sprintf("%s/api/v2/torrents/%s", b.cfg.BaseURL, action), form)
+	if err != nil {
+		return fmt.Errorf("qbittorrent %s failed: %w", action, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qbittorrent %s failed: status %d", action, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Pause pauses the torrent identified by infoHash.
+func (b *Backend) Pause(infoHash string) error {
+	return b.hashAction("pause", infoHash)
+}
+
+// Resume resumes the torrent identified by infoHash.
+func (b *Backend) Resume(infoHash string) error {
+	return b.hashAction("resume", infoHash)
+}
+
+// Remove stops tracking the torrent identified by infoHash, optionally
+// deleting its downloaded files.
+func (b *Backend) Remove(infoHash string, deleteFiles bool) error {
+	form := url.Values{"hashes": {infoHash}, "deleteFiles": {strconv.FormatBool(deleteFiles)}}
+
+	resp, err := b.client.PostForm(b.cfg.BaseURL+"/api/v2/torrents/delete", form)
+	if err != nil {
+		return fmt.Errorf("qbittorrent delete failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qbittorrent delete failed: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type torrentInfo struct {
+	Hash      string  `json:"hash"`
+	Name      string  `json:"name"`
+	Size      int64   `json:"size"`
+	Completed int64   `json:"completed"`
+	DlSpeed   float64 `json:"dlspeed"`
+	NumSeeds  int     `json:"num_seeds"`
+	State     string  `json:"state"`
+}
+
+// List fetches the current torrents from /api/v2/torrents/info. It
+// returns nil, rather than an error, if the instance can't be reached,
+// so a transient network blip doesn't interrupt the poller.
+func (b *Backend) List() []downloader.TorrentState {
+	resp, err := b.client.Get(b.cfg.BaseURL + "/api/v2/torrents/info")
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var infos []torrentInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil
+	}
+
+	states := make([]downloader.TorrentState, 0, len(infos))
+	for _, in := range infos {
+		states = append(states, in.toState())
+	}
+	return states
+}
+
+func (in torrentInfo) toState() downloader.TorrentState {
+	status := downloader.StatusDownloading
+	switch in.State {
+	case "pausedDL", "pausedUP":
+		status = downloader.StatusPaused
+	case "uploading", "stalledUP", "forcedUP":
+		status = downloader.StatusComplete
+	}
+
+	return downloader.TorrentState{
+		InfoHash:       in.Hash,
+		Name:           in.Name,
+		Status:         status,
+		BytesCompleted: in.Completed,
+		TotalBytes:     in.Size,
+		DownloadRate:   in.DlSpeed,
+		Seeders:        in.NumSeeds,
+	}
+}
+
+// Progress returns a channel fed by a Poller over List, since the Web
+// API has no push-based progress notifications.
+func (b *Backend) Progress() <-chan downloader.TorrentState {
+	return b.poller.Subscribe()
+}
+
+// withMagnetParams appends trackers and webseeds to magnet as extra
+// &tr= and &ws= (BEP-19) query parameters.
+func withMagnetParams(magnet string, trackers, webSeeds []string) string {
+	var b strings.Builder
+	b.WriteString(magnet)
+
+	for _, tr := range trackers {
+		b.WriteString("&tr=")
+		b.WriteString(url.QueryEscape(tr))
+	}
+	for _, ws := range webSeeds {
+		b.WriteString("&ws=")
+		b.WriteString(url.QueryEscape(ws))
+	}
+
+	return b.String()
+}
+
+var _ downloader.Backend = (*Backend)(nil)
@@ -0,0 +1,425 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"golang.org/x/time/rate"
+)
+
+// AddOptions customizes how Engine.Add (and, by extension, CreateAndSeed)
+// brings a torrent into the client.
+type AddOptions struct {
+	// Seed marks the torrent as an upload source, overriding the
+	// client-wide NoUpload default for just this torrent.
+	Seed bool
+	// Trackers augments the torrent's own tracker list with additional
+	// announce URLs, applied as one extra tier.
+	Trackers []string
+	// WebSeeds are HTTP sources (BEP-19) activated as a fallback once the
+	// torrent has gone webSeedGracePeriod without a single peer connection.
+	WebSeeds []string
+}
+
+// webSeedGracePeriod is how long a torrent is given to find real peers
+// before its configured webseeds are activated as a fallback source.
+const webSeedGracePeriod = 30 * time.Second
+
+// applyTrackers registers extra trackers on t as one additional
+// announce tier, alongside whatever the torrent/magnet already had.
+func applyTrackers(t *torrent.Torrent, trackers []string) {
+	if len(trackers) == 0 {
+		return
+	}
+	t.AddTrackers([][]string{trackers})
+}
+
+// watchWebSeedFallback activates webSeeds on t if it still has no peer
+// connections after webSeedGracePeriod, so swarms with no seeders but a
+// published HTTP mirror (e.g. Linux ISOs) keep making progress. stop
+// aborts the wait early, e.g. when the torrent is removed or the client
+// is closed.
+func watchWebSeedFallback(t *torrent.Torrent, webSeeds []string, stop <-chan struct{}) {
+	if len(webSeeds) == 0 {
+		return
+	}
+
+	select {
+	case <-time.After(webSeedGracePeriod):
+	case <-stop:
+		return
+	}
+
+	if len(t.PeerConns()) == 0 {
+		t.AddWebSeeds(webSeeds)
+	}
+}
+
+// PieceRun describes a contiguous run of pieces sharing the same state,
+// mirroring torrent.Torrent.PieceStateRuns().
+type PieceRun struct {
+	Length   int
+	Complete bool
+	Partial  bool
+	Checking bool
+	Priority torrent.PiecePriority
+}
+
+// ProgressInfo contains information about download progress
+type ProgressInfo struct {
+	PercentDone      float64
+	BytesCompleted   int64
+	TotalBytes       int64
+	PeersConnected   int
+	DownloadSpeed    float64 // EMA bytes per second
+	UploadSpeed      float64 // EMA bytes per second
+	PieceStates      []PieceRun
+	BytesReadData    int64
+	BytesWrittenData int64
+}
+
+// unlimitedRate is used as the rate.Limit equivalent of "no limit" for
+// SetDownloadLimit/SetUploadLimit's bytesPerSec == 0 case.
+const unlimitedRate = rate.Inf
+
+// TorrentDownloader manages torrent downloads
+type TorrentDownloader struct {
+	client          *torrent.Client
+	downloadPath    string
+	downloadLimiter *rate.Limiter
+	uploadLimiter   *rate.Limiter
+}
+
+// NewTorrentDownloader creates a new downloader instance
+func NewTorrentDownloader(downloadPath string) (*TorrentDownloader, error) {
+	client, limiters, err := newClient(downloadPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TorrentDownloader{
+		client:          client,
+		downloadPath:    downloadPath,
+		downloadLimiter: limiters.download,
+		uploadLimiter:   limiters.upload,
+	}, nil
+}
+
+// SetDownloadLimit caps download throughput to bytesPerSec across every
+// torrent on this client; 0 means unlimited.
+func (td *TorrentDownloader) SetDownloadLimit(bytesPerSec int64) {
+	setLimiterRate(td.downloadLimiter, bytesPerSec)
+}
+
+// SetUploadLimit caps upload throughput to bytesPerSec across every
+// torrent on this client; 0 means unlimited.
+func (td *TorrentDownloader) SetUploadLimit(bytesPerSec int64) {
+	setLimiterRate(td.uploadLimiter, bytesPerSec)
+}
+
+// setLimiterRate applies bytesPerSec to limiter, treating 0 as unlimited.
+func setLimiterRate(limiter *rate.Limiter, bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		limiter.SetLimit(unlimitedRate)
+		return
+	}
+	limiter.SetLimit(rate.Limit(bytesPerSec))
+}
+
+// rateLimiters bundles the client's download/upload limiters so they can
+// be retained by whoever builds the client and adjusted afterwards.
+type rateLimiters struct {
+	download *rate.Limiter
+	upload   *rate.Limiter
+}
+
+// newClient builds a torrent.Client with the disk-based storage and
+// connection limits shared by TorrentDownloader and Engine.
+func newClient(downloadPath string) (*torrent.Client, rateLimiters, error) {
+	// Create download directory if it doesn't exist
+	if err := os.MkdirAll(downloadPath, 0755); err != nil {
+		return nil, rateLimiters{}, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	// Create a torrent client with disk-based storage
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DefaultStorage = storage.NewFile(downloadPath)
+	cfg.DataDir = downloadPath
+
+	// Set reasonable limits to prevent system overload
+	cfg.EstablishedConnsPerTorrent = 31
+	cfg.Seed = false       // Set to true if you want to seed after download
+	cfg.DisableIPv6 = true // Disable IPv6 if it's causing issues
+	cfg.DisableTCP = false // Keep TCP enabled for better connectivity
+	cfg.DisableUTP = false // Keep uTP enabled for better NAT traversal
+	cfg.NoDHT = false      // Keep DHT enabled for better peer discovery
+	cfg.NoUpload = true    // Disable uploading to improve download performance
+	cfg.ListenPort = 0
+
+	// Unlimited by default; Set{Download,Upload}Limit tighten these later.
+	const defaultBurst = 1 << 20 // 1 MiB, large enough not to stall bursty reads/writes
+	limiters := rateLimiters{
+		download: rate.NewLimiter(unlimitedRate, defaultBurst),
+		upload:   rate.NewLimiter(unlimitedRate, defaultBurst),
+	}
+	cfg.DownloadRateLimiter = limiters.download
+	cfg.UploadRateLimiter = limiters.upload
+
+	client, err := torrent.NewClient(cfg)
+	if err != nil {
+		return nil, rateLimiters{}, fmt.Errorf("failed to create torrent client: %w", err)
+	}
+
+	return client, limiters, nil
+}
+
+// Close shuts down the torrent client
+func (td *TorrentDownloader) Close() error {
+	td.client.Close()
+	return nil
+}
+
+// magnetRegexp matches magnet URIs.
+var magnetRegexp = regexp.MustCompile(`^magnet:\?`)
+
+// httpMetainfoRegexp matches torrent+http:// and torrent+https:// metainfo URLs.
+var httpMetainfoRegexp = regexp.MustCompile(`^torrent\+(https?)://`)
+
+// Download accepts a magnet URI, a local path to a .torrent file, or a
+// torrent+https:// / torrent+http:// URL pointing at a metainfo file, and
+// downloads it, reporting progress through progressCallback. opts is
+// optional; only its first value is used, if given.
+func (td *TorrentDownloader) Download(
+	ctx context.Context,
+	source string,
+	progressCallback func(ProgressInfo),
+	opts ...AddOptions,
+) (string, error) {
+	var opt AddOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	t, err := td.addTorrent(source)
+	if err != nil {
+		return "", err
+	}
+
+	applyTrackers(t, opt.Trackers)
+	go watchWebSeedFallback(t, opt.WebSeeds, ctx.Done())
+
+	return td.awaitDownload(ctx, t, progressCallback)
+}
+
+// addTorrent dispatches on the shape of source and adds the resulting
+// torrent to the client without waiting for it to complete.
+func (td *TorrentDownloader) addTorrent(source string) (*torrent.Torrent, error) {
+	return addTorrentSource(td.client, source)
+}
+
+// addTorrentSource dispatches on the shape of source (magnet URI, local
+// .torrent path, or torrent+http(s):// metainfo URL) and adds the
+// resulting torrent to client without waiting for it to complete.
+func addTorrentSource(client *torrent.Client, source string) (*torrent.Torrent, error) {
+	switch {
+	case magnetRegexp.MatchString(source):
+		t, err := client.AddMagnet(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add magnet: %w", err)
+		}
+		return t, nil
+	case httpMetainfoRegexp.MatchString(source):
+		mi, err := fetchMetainfo(source)
+		if err != nil {
+			return nil, err
+		}
+		t, err := client.AddTorrent(mi)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add torrent: %w", err)
+		}
+		return t, nil
+	default:
+		mi, err := metainfo.LoadFromFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load torrent file %q: %w", source, err)
+		}
+		t, err := client.AddTorrent(mi)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add torrent: %w", err)
+		}
+		return t, nil
+	}
+}
+
+// fetchMetainfo downloads the metainfo file referenced by a
+// torrent+http(s):// URL and parses it.
+func fetchMetainfo(source string) (*metainfo.MetaInfo, error) {
+	httpURL := httpMetainfoRegexp.ReplaceAllString(source, "$1://")
+
+	resp, err := http.Get(httpURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metainfo from %q: %w", httpURL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch metainfo from %q: status %s", httpURL, resp.Status)
+	}
+
+	mi, err := metainfo.Load(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metainfo from %q: %w", httpURL, err)
+	}
+
+	return mi, nil
+}
+
+// DownloadFromMagnet downloads a file from a magnet link
+func (td *TorrentDownloader) DownloadFromMagnet(
+	ctx context.Context,
+	magnetLink string,
+	progressCallback func(ProgressInfo),
+) (string, error) {
+	return td.Download(ctx, magnetLink, progressCallback)
+}
+
+// emaAlpha smooths the rate estimate over roughly the last 10 samples
+// (1 sample/sec), rather than jittering on a single-second delta.
+const emaAlpha = 0.2
+
+// rateEMA tracks an exponential moving average of a monotonically
+// increasing byte counter sampled once per tick.
+type rateEMA struct {
+	last    int64
+	lastSet bool
+	rate    float64
+}
+
+// update feeds the latest cumulative byte count into the average and
+// returns the current bytes/sec estimate.
+func (e *rateEMA) update(current int64, elapsed float64) float64 {
+	if !e.lastSet {
+		e.last, e.lastSet = current, true
+		return 0
+	}
+
+	var instant float64
+	if elapsed > 0 {
+		instant = float64(current-e.last) / elapsed
+	}
+	e.last = current
+
+	if e.rate == 0 {
+		e.rate = instant
+	} else {
+		e.rate = emaAlpha*instant + (1-emaAlpha)*e.rate
+	}
+	return e.rate
+}
+
+// pieceStates converts t.PieceStateRuns() into the package's PieceRun
+// representation for reporting to the GUI.
+func pieceStates(t *torrent.Torrent) []PieceRun {
+	runs := t.PieceStateRuns()
+	states := make([]PieceRun, 0, len(runs))
+	for _, run := range runs {
+		states = append(states, PieceRun{
+			Length:   run.Length,
+			Complete: run.Complete,
+			Partial:  run.Partial,
+			Checking: run.Checking,
+			Priority: run.Priority,
+		})
+	}
+	return states
+}
+
+// awaitDownload waits for metadata, starts the download and reports
+// progress until the torrent completes or the context is cancelled.
+func (td *TorrentDownloader) awaitDownload(
+	ctx context.Context,
+	t *torrent.Torrent,
+	progressCallback func(ProgressInfo),
+) (string, error) {
+	// Wait for torrent metadata with context
+	select {
+	case <-t.GotInfo():
+		// Got the info
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(2 * time.Minute):
+		return "", errors.New("timeout waiting for torrent metadata")
+	}
+
+	// Start downloading
+	t.DownloadAll()
+
+	// Create a ticker for progress updates
+	progressTicker := time.NewTicker(1 * time.Second)
+	defer progressTicker.Stop()
+
+	var downloadEMA, uploadEMA rateEMA
+	lastTime := time.Now()
+
+	// Monitor download progress
+	for {
+		select {
+		case <-progressTicker.C:
+			currentTime := time.Now()
+			elapsed := currentTime.Sub(lastTime).Seconds()
+			lastTime = currentTime
+
+			bytesCompleted := t.BytesCompleted()
+			totalBytes := t.Length()
+			percentDone := float64(bytesCompleted) / float64(totalBytes) * 100
+
+			stats := t.Stats()
+			bytesReadData := stats.BytesReadData.Int64()
+			bytesWrittenData := stats.BytesWrittenData.Int64()
+			downloadSpeed := downloadEMA.update(bytesReadData, elapsed)
+			uploadSpeed := uploadEMA.update(bytesWrittenData, elapsed)
+
+			if progressCallback != nil {
+				progressCallback(ProgressInfo{
+					PercentDone:      percentDone,
+					BytesCompleted:   bytesCompleted,
+					TotalBytes:       totalBytes,
+					PeersConnected:   len(t.PeerConns()),
+					DownloadSpeed:    downloadSpeed,
+					UploadSpeed:      uploadSpeed,
+					PieceStates:      pieceStates(t),
+					BytesReadData:    bytesReadData,
+					BytesWrittenData: bytesWrittenData,
+				})
+			}
+
+			// Check if download is complete
+			if t.Complete().Bool() {
+				// Get the path to the downloaded file
+				info := t.Info()
+				if len(info.Files) == 0 {
+					// Single file torrent
+					filePath := filepath.Join(td.downloadPath, info.Name)
+					return filePath, nil
+				}
+				// Return the path to the directory for multi-file torrents
+				return filepath.Join(td.downloadPath, info.Name), nil
+			}
+
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
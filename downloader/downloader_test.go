@@ -1,4 +1,4 @@
-package main
+package downloader
 
 import (
 	"context"
@@ -31,7 +31,7 @@ func Test_Download(t *testing.T) {
 
 	fmt.Println("Starting download from magnet link...")
 	dt, _ := NewTorrentDownloader(".")
-	fileName, err := dt.DownloadFromMagnet(ctx, magnetLink, progressCb)
+	fileName, err := dt.Download(ctx, magnetLink, progressCb)
 	if err != nil {
 		slog.Error("Error downloading from magnet:", "err", err)
 	}
@@ -40,3 +40,32 @@ func Test_Download(t *testing.T) {
 
 	fmt.Println("File saved to 'downloaded_file'")
 }
+
+func Test_rateEMA_update(t *testing.T) {
+	var e rateEMA
+
+	// The first sample only seeds the counter; it can't yet produce a
+	// rate.
+	if got := e.update(0, 1); got != 0 {
+		t.Fatalf("first update() = %v, want 0", got)
+	}
+
+	// A steady 100 bytes/sec should converge towards 100 over a few
+	// samples rather than jumping there in one tick.
+	var got float64
+	for i := 0; i < 20; i++ {
+		got = e.update(int64(100*(i+1)), 1)
+	}
+	if got < 95 || got > 100 {
+		t.Fatalf("update() settled at %v, want close to 100", got)
+	}
+}
+
+func Test_rateEMA_update_zeroElapsed(t *testing.T) {
+	var e rateEMA
+	e.update(0, 1)
+
+	if got := e.update(100, 0); got != 0 {
+		t.Fatalf("update() with zero elapsed = %v, want 0", got)
+	}
+}
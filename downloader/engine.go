@@ -0,0 +1,502 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"golang.org/x/time/rate"
+)
+
+// Status describes the lifecycle state of a torrent tracked by an Engine.
+type Status string
+
+const (
+	StatusDownloading Status = "downloading"
+	StatusPaused      Status = "paused"
+	StatusComplete    Status = "complete"
+)
+
+// FileState reports the completion of a single file within a torrent.
+type FileState struct {
+	Path           string
+	Length         int64
+	BytesCompleted int64
+}
+
+// TorrentState is a snapshot of a tracked torrent's progress and settings.
+type TorrentState struct {
+	InfoHash         string
+	Name             string
+	Label            string
+	Status           Status
+	BytesCompleted   int64
+	TotalBytes       int64
+	DownloadRate     float64 // EMA bytes per second
+	UploadRate       float64 // EMA bytes per second
+	Seeders          int
+	Files            []FileState
+	AddedAt          time.Time
+	PieceStates      []PieceRun
+	BytesReadData    int64
+	BytesWrittenData int64
+}
+
+// stateFileName is where Engine persists the set of tracked torrents,
+// relative to the download directory.
+const stateFileName = "cactus-engine-state.json"
+
+// persistedTorrent is the on-disk representation of a tracked torrent,
+// enough to re-add it to the client on restart.
+type persistedTorrent struct {
+	Source   string    `json:"source"`
+	Label    string    `json:"label"`
+	Paused   bool      `json:"paused"`
+	Seed     bool      `json:"seed"`
+	Trackers []string  `json:"trackers,omitempty"`
+	WebSeeds []string  `json:"web_seeds,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+	// StorageRoot is the directory CreateAndSeed rooted this torrent's
+	// storage at, when Source is a .torrent file seeding content that
+	// lives outside the download directory. Empty for torrents added
+	// through Add, which use the client's default storage.
+	StorageRoot string `json:"storage_root,omitempty"`
+}
+
+// trackedTorrent is the in-memory bookkeeping an Engine keeps alongside
+// each torrent.Torrent it manages.
+type trackedTorrent struct {
+	t           *torrent.Torrent
+	source      string
+	label       string
+	paused      bool
+	seed        bool
+	trackers    []string
+	webSeeds    []string
+	addedAt     time.Time
+	storageRoot string
+
+	// stop is closed by Remove to cancel this torrent's own background
+	// goroutines (e.g. watchWebSeedFallback) without waiting on the
+	// engine-wide e.stop.
+	stop chan struct{}
+
+	// gotInfo is set once t.GotInfo() has fired, by the same goroutine
+	// in track() that waits on it. Pause/Resume must not touch
+	// piece/priority APIs (NumPieces, DownloadAll) before this is true:
+	// those dereference the torrent's info, which is nil until then.
+	gotInfo bool
+
+	lastTime    time.Time
+	downloadEMA rateEMA
+	uploadEMA   rateEMA
+}
+
+// trackParams groups the settings track() needs to register a torrent,
+// keeping the (source, label, seed, paused, trackers, webSeeds, addedAt)
+// tuple from sprawling across every call site.
+type trackParams struct {
+	source   string
+	label    string
+	seed     bool
+	paused   bool
+	trackers []string
+	webSeeds []string
+	addedAt  time.Time
+	// storageRoot is the directory a seeded torrent's data actually
+	// lives under, when it differs from the engine's download
+	// directory (see CreateAndSeed). Empty for downloaded torrents.
+	storageRoot string
+}
+
+var _ Backend = (*Engine)(nil)
+
+// Engine owns a single torrent.Client and tracks a set of torrents that
+// can be paused, resumed and removed independently, persisting the set
+// across restarts.
+type Engine struct {
+	client       *torrent.Client
+	downloadPath string
+
+	downloadLimiter *rate.Limiter
+	uploadLimiter   *rate.Limiter
+
+	mu       sync.Mutex
+	torrents map[metainfo.Hash]*trackedTorrent
+
+	poller *Poller
+	stop   chan struct{}
+}
+
+// NewEngine creates an Engine rooted at downloadPath and resumes any
+// torrents that were active the last time the Engine ran.
+func NewEngine(downloadPath string) (*Engine, error) {
+	client, limiters, err := newClient(downloadPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		client:          client,
+		downloadPath:    downloadPath,
+		downloadLimiter: limiters.download,
+		uploadLimiter:   limiters.upload,
+		torrents:        make(map[metainfo.Hash]*trackedTorrent),
+		stop:            make(chan struct{}),
+	}
+
+	if err := e.restore(); err != nil {
+		return nil, fmt.Errorf("failed to restore torrents: %w", err)
+	}
+
+	e.poller = NewPoller(time.Second, e.List)
+
+	return e, nil
+}
+
+// Close shuts down the torrent client and stops publishing state updates.
+func (e *Engine) Close() error {
+	close(e.stop)
+	e.poller.Close()
+	e.client.Close()
+	return nil
+}
+
+// SetDownloadLimit caps download throughput to bytesPerSec across every
+// torrent on this Engine's client; 0 means unlimited.
+func (e *Engine) SetDownloadLimit(bytesPerSec int64) {
+	setLimiterRate(e.downloadLimiter, bytesPerSec)
+}
+
+// SetUploadLimit caps upload throughput to bytesPerSec across every
+// torrent on this Engine's client; 0 means unlimited.
+func (e *Engine) SetUploadLimit(bytesPerSec int64) {
+	setLimiterRate(e.uploadLimiter, bytesPerSec)
+}
+
+// Add starts tracking source (a magnet URI, local .torrent path, or
+// torrent+http(s):// metainfo URL) and returns its infohash, hex-encoded.
+func (e *Engine) Add(source string, opts AddOptions) (string, error) {
+	t, err := addTorrentSource(e.client, source)
+	if err != nil {
+		return "", err
+	}
+
+	e.track(t, trackParams{
+		source:   source,
+		seed:     opts.Seed,
+		trackers: opts.Trackers,
+		webSeeds: opts.WebSeeds,
+		addedAt:  time.Now(),
+	})
+
+	if err := e.persist(); err != nil {
+		return "", err
+	}
+
+	return t.InfoHash().HexString(), nil
+}
+
+// track registers t with the engine, starting its download unless
+// paused, allowing it to upload if seed is set, augmenting its trackers,
+// and arming the webseed fallback watcher.
+func (e *Engine) track(t *torrent.Torrent, p trackParams) {
+	tt := &trackedTorrent{
+		t:           t,
+		source:      p.source,
+		label:       p.label,
+		paused:      p.paused,
+		seed:        p.seed,
+		trackers:    p.trackers,
+		webSeeds:    p.webSeeds,
+		addedAt:     p.addedAt,
+		storageRoot: p.storageRoot,
+		stop:        make(chan struct{}),
+		lastTime:    time.Now(),
+	}
+
+	e.mu.Lock()
+	e.torrents[t.InfoHash()] = tt
+	e.mu.Unlock()
+
+	// Cancel the webseed watcher on whichever comes first: this
+	// torrent being removed, or the whole engine shutting down.
+	watcherStop := make(chan struct{})
+	go func() {
+		select {
+		case <-tt.stop:
+		case <-e.stop:
+		}
+		close(watcherStop)
+	}()
+	go watchWebSeedFallback(t, p.webSeeds, watcherStop)
+
+	go func() {
+		select {
+		case <-t.GotInfo():
+		case <-e.stop:
+			return
+		}
+		applyTrackers(t, p.trackers)
+		if p.seed {
+			t.AllowDataUpload()
+		}
+
+		// Read paused from the tracked struct rather than the captured
+		// p.paused: a Pause call that lands while metadata is still
+		// in flight must not be overridden once GotInfo fires.
+		e.mu.Lock()
+		paused := tt.paused
+		tt.gotInfo = true
+		e.mu.Unlock()
+		if !paused {
+			t.DownloadAll()
+		}
+	}()
+}
+
+// Pause stops data transfer for the torrent identified by ih without
+// removing it or its downloaded data.
+func (e *Engine) Pause(ih string) error {
+	tt, err := e.get(ih)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	tt.paused = true
+	gotInfo := tt.gotInfo
+	e.mu.Unlock()
+
+	// Before metadata arrives, NumPieces (and so CancelPieces) would
+	// dereference the torrent's nil info; there's nothing downloading
+	// yet to cancel anyway. track()'s GotInfo goroutine already checks
+	// paused before calling DownloadAll, so setting the flag above is
+	// enough to keep it paused once metadata does arrive.
+	if gotInfo {
+		tt.t.CancelPieces(0, tt.t.NumPieces())
+	}
+
+	return e.persist()
+}
+
+// Resume restarts data transfer for a previously paused torrent.
+func (e *Engine) Resume(ih string) error {
+	tt, err := e.get(ih)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	tt.paused = false
+	gotInfo := tt.gotInfo
+	e.mu.Unlock()
+
+	// If metadata hasn't arrived yet, track()'s GotInfo goroutine will
+	// see paused == false and call DownloadAll itself once it does.
+	if gotInfo {
+		tt.t.DownloadAll()
+	}
+
+	return e.persist()
+}
+
+// Remove stops tracking the torrent identified by ih, optionally
+// deleting its downloaded files from disk.
+func (e *Engine) Remove(ih string, deleteFiles bool) error {
+	tt, err := e.get(ih)
+	if err != nil {
+		return err
+	}
+
+	info := tt.t.Info()
+	close(tt.stop)
+	tt.t.Drop()
+
+	e.mu.Lock()
+	delete(e.torrents, metainfo.NewHashFromHex(ih))
+	e.mu.Unlock()
+
+	if deleteFiles && info != nil {
+		if err := os.RemoveAll(filepath.Join(e.downloadPath, info.Name)); err != nil {
+			return fmt.Errorf("failed to delete downloaded files: %w", err)
+		}
+	}
+
+	return e.persist()
+}
+
+// List returns a snapshot of every torrent currently tracked.
+func (e *Engine) List() []TorrentState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	states := make([]TorrentState, 0, len(e.torrents))
+	for _, tt := range e.torrents {
+		states = append(states, tt.state())
+	}
+	return states
+}
+
+// Subscribe returns a channel that receives a TorrentState update
+// whenever a tracked torrent's progress changes. The channel is closed
+// when the Engine is closed.
+func (e *Engine) Subscribe() <-chan TorrentState {
+	return e.poller.Subscribe()
+}
+
+// Progress satisfies the Backend interface; it's equivalent to Subscribe.
+func (e *Engine) Progress() <-chan TorrentState {
+	return e.poller.Subscribe()
+}
+
+// get looks up a tracked torrent by hex-encoded infohash.
+func (e *Engine) get(ih string) (*trackedTorrent, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tt, ok := e.torrents[metainfo.NewHashFromHex(ih)]
+	if !ok {
+		return nil, fmt.Errorf("torrent %s is not tracked by this engine", ih)
+	}
+	return tt, nil
+}
+
+// state builds a TorrentState snapshot for a tracked torrent, updating
+// its rolling byte/time counters for the next rate calculation.
+func (tt *trackedTorrent) state() TorrentState {
+	bytesCompleted := tt.t.BytesCompleted()
+
+	now := time.Now()
+	elapsed := now.Sub(tt.lastTime).Seconds()
+	tt.lastTime = now
+
+	stats := tt.t.Stats()
+	bytesReadData := stats.BytesReadData.Int64()
+	bytesWrittenData := stats.BytesWrittenData.Int64()
+	downloadRate := tt.downloadEMA.update(bytesReadData, elapsed)
+	uploadRate := tt.uploadEMA.update(bytesWrittenData, elapsed)
+
+	status := StatusDownloading
+	switch {
+	case tt.paused:
+		status = StatusPaused
+	case tt.t.Complete().Bool():
+		status = StatusComplete
+	}
+
+	var files []FileState
+	if info := tt.t.Info(); info != nil {
+		for _, f := range tt.t.Files() {
+			files = append(files, FileState{
+				Path:           f.Path(),
+				Length:         f.Length(),
+				BytesCompleted: f.BytesCompleted(),
+			})
+		}
+	}
+
+	name := tt.t.Name()
+
+	return TorrentState{
+		InfoHash:         tt.t.InfoHash().HexString(),
+		Name:             name,
+		Label:            tt.label,
+		Status:           status,
+		BytesCompleted:   bytesCompleted,
+		TotalBytes:       tt.t.Length(),
+		DownloadRate:     downloadRate,
+		UploadRate:       uploadRate,
+		Seeders:          len(tt.t.PeerConns()),
+		Files:            files,
+		AddedAt:          tt.addedAt,
+		PieceStates:      pieceStates(tt.t),
+		BytesReadData:    bytesReadData,
+		BytesWrittenData: bytesWrittenData,
+	}
+}
+
+// persist writes the current set of tracked torrents to the state file
+// so they can be resumed on the next Engine startup.
+func (e *Engine) persist() error {
+	e.mu.Lock()
+	entries := make([]persistedTorrent, 0, len(e.torrents))
+	for _, tt := range e.torrents {
+		entries = append(entries, persistedTorrent{
+			Source:      tt.source,
+			Label:       tt.label,
+			Paused:      tt.paused,
+			Seed:        tt.seed,
+			Trackers:    tt.trackers,
+			WebSeeds:    tt.webSeeds,
+			AddedAt:     tt.addedAt,
+			StorageRoot: tt.storageRoot,
+		})
+	}
+	e.mu.Unlock()
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal engine state: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(e.downloadPath, stateFileName), b, 0644); err != nil {
+		return fmt.Errorf("failed to write engine state: %w", err)
+	}
+
+	return nil
+}
+
+// restore re-adds every torrent recorded in the state file, preserving
+// its paused flag, label and added-at timestamp.
+func (e *Engine) restore() error {
+	b, err := os.ReadFile(filepath.Join(e.downloadPath, stateFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []persistedTorrent
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return fmt.Errorf("failed to parse engine state: %w", err)
+	}
+
+	for _, entry := range entries {
+		var (
+			t   *torrent.Torrent
+			err error
+		)
+		if entry.StorageRoot != "" {
+			// Seeded by CreateAndSeed: its content lives outside the
+			// download directory, so it needs its original storage
+			// root rather than the client's default storage.
+			t, err = addSeededTorrent(e.client, entry.Source, entry.StorageRoot)
+		} else {
+			t, err = addTorrentSource(e.client, entry.Source)
+		}
+		if err != nil {
+			// A source that no longer resolves (e.g. a dead HTTP
+			// metainfo link) shouldn't prevent the rest from resuming.
+			continue
+		}
+		e.track(t, trackParams{
+			source:      entry.Source,
+			label:       entry.Label,
+			seed:        entry.Seed,
+			paused:      entry.Paused,
+			trackers:    entry.Trackers,
+			webSeeds:    entry.WebSeeds,
+			addedAt:     entry.AddedAt,
+			storageRoot: entry.StorageRoot,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func Test_Engine_PersistRestoreRoundTrip(t *testing.T) {
+	downloadDir := t.TempDir()
+	contentDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(contentDir, "file.txt"), []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	e, err := NewEngine(downloadDir)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	if _, _, err := e.CreateAndSeed(contentDir, SeedOptions{}); err != nil {
+		t.Fatalf("CreateAndSeed() error = %v", err)
+	}
+
+	e.mu.Lock()
+	var ih metainfo.Hash
+	var wantRoot string
+	for h, tt := range e.torrents {
+		ih, wantRoot = h, tt.storageRoot
+	}
+	e.mu.Unlock()
+
+	if wantRoot == "" {
+		t.Fatal("CreateAndSeed did not record a storage root")
+	}
+
+	if err := e.Pause(ih.HexString()); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	e2, err := NewEngine(downloadDir)
+	if err != nil {
+		t.Fatalf("NewEngine() on restore error = %v", err)
+	}
+	defer e2.Close()
+
+	e2.mu.Lock()
+	restored, ok := e2.torrents[ih]
+	e2.mu.Unlock()
+
+	if !ok {
+		t.Fatalf("restore() did not re-add torrent %s", ih.HexString())
+	}
+	if !restored.paused {
+		t.Error("restore() lost the paused flag")
+	}
+	if restored.storageRoot != wantRoot {
+		t.Errorf("restore() storageRoot = %q, want %q (content would be re-fetched into the wrong directory)", restored.storageRoot, wantRoot)
+	}
+}
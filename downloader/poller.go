@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+)
+
+// Poller periodically calls a snapshot function and fans the resulting
+// TorrentStates out to subscribers. Engine uses one to drive its own
+// Subscribe/Progress channel; remote backends (qBittorrent, Aria2) have
+// no push notifications of their own, so they use a Poller too, built
+// around whatever RPC call lists their torrents.
+type Poller struct {
+	stop chan struct{}
+
+	subsMu sync.Mutex
+	subs   []chan TorrentState
+}
+
+// NewPoller starts calling snapshot every interval, broadcasting each
+// returned TorrentState to subscribers, until Close is called.
+func NewPoller(interval time.Duration, snapshot func() []TorrentState) *Poller {
+	p := &Poller{stop: make(chan struct{})}
+	go p.run(interval, snapshot)
+	return p
+}
+
+func (p *Poller) run(interval time.Duration, snapshot func() []TorrentState) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, state := range snapshot() {
+				p.broadcast(state)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// broadcast fans state out to every subscriber without blocking on a
+// slow or abandoned reader.
+func (p *Poller) broadcast(state TorrentState) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+
+	for _, ch := range p.subs {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel that receives a TorrentState update on
+// every poll.
+func (p *Poller) Subscribe() <-chan TorrentState {
+	ch := make(chan TorrentState, 16)
+
+	p.subsMu.Lock()
+	p.subs = append(p.subs, ch)
+	p.subsMu.Unlock()
+
+	return ch
+}
+
+// Close stops polling.
+func (p *Poller) Close() {
+	close(p.stop)
+}
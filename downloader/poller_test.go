@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Poller_SubscribeBroadcast(t *testing.T) {
+	want := TorrentState{InfoHash: "abc", Name: "test"}
+
+	p := NewPoller(5*time.Millisecond, func() []TorrentState {
+		return []TorrentState{want}
+	})
+	defer p.Close()
+
+	sub := p.Subscribe()
+
+	select {
+	case got := <-sub:
+		if got.InfoHash != want.InfoHash || got.Name != want.Name {
+			t.Fatalf("Subscribe() received %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for broadcast state")
+	}
+}
+
+func Test_Poller_SlowSubscriberDoesNotBlock(t *testing.T) {
+	const interval = time.Millisecond
+
+	p := NewPoller(interval, func() []TorrentState {
+		return []TorrentState{{InfoHash: "abc"}}
+	})
+	defer p.Close()
+
+	// Never drained: once its buffer (cap 16) fills, broadcast must drop
+	// its updates rather than block on it.
+	slow := p.Subscribe()
+	fast := p.Subscribe()
+
+	// Let slow's buffer fill completely.
+	time.Sleep(32 * interval)
+
+	// If broadcast were blocking on the full slow channel, fast would
+	// stop receiving ticks too. Require it keeps making progress for a
+	// bound tied to the poller's own tick interval, not an unrelated
+	// timer.
+	const wantTicks = 20
+	timeout := time.After(wantTicks * interval * 50)
+	for i := 0; i < wantTicks; i++ {
+		select {
+		case <-fast:
+		case <-timeout:
+			t.Fatalf("broadcast blocked on a slow subscriber: only received %d/%d ticks on fast", i, wantTicks)
+		}
+	}
+
+	// Confirm the slow subscriber really was the one filling up, not
+	// just unlucky scheduling.
+	select {
+	case <-slow:
+	default:
+		t.Fatal("slow subscriber's buffer was never exercised")
+	}
+}
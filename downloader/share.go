@@ -0,0 +1,181 @@
+package downloader
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// SeedOptions configures CreateAndSeed.
+type SeedOptions struct {
+	// Trackers is the tracker list to announce to; the first entry
+	// becomes the primary announce URL, and all of them are grouped
+	// into a single AnnounceList tier.
+	Trackers []string
+	// Label is stored alongside the torrent the same way Add's caller
+	// would set one, for display in the Control Panel.
+	Label string
+	// PieceLength overrides the piece-length heuristic; 0 picks one
+	// automatically based on the content size.
+	PieceLength int64
+}
+
+// pieceLengthFor picks a piece length that keeps a torrent's piece count
+// in a reasonable range, the same heuristic most BitTorrent clients use:
+// start small for tiny content and grow as content size increases, capping
+// out at a sane maximum so the .torrent file doesn't balloon in size.
+func pieceLengthFor(totalSize int64) int64 {
+	const (
+		minPieceLength = 256 << 10 // 256 KiB
+		maxPieceLength = 16 << 20  // 16 MiB
+		targetPieces   = 1500
+	)
+
+	length := int64(minPieceLength)
+	for totalSize/length > targetPieces && length < maxPieceLength {
+		length *= 2
+	}
+	return length
+}
+
+// CreateAndSeed builds a .torrent for the file or directory at path,
+// writes it alongside the content, and starts seeding it on this
+// Engine's client. It returns the magnet URI and the path to the
+// written .torrent file.
+func (e *Engine) CreateAndSeed(path string, opts SeedOptions) (magnet string, torrentPath string, err error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+
+	stat, err := os.Stat(absPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat %q: %w", absPath, err)
+	}
+
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = pieceLengthFor(totalSize(absPath, stat))
+	}
+
+	info := metainfo.Info{PieceLength: pieceLength}
+	if err := info.BuildFromFilePath(absPath); err != nil {
+		return "", "", fmt.Errorf("failed to build torrent info for %q: %w", absPath, err)
+	}
+
+	mi := &metainfo.MetaInfo{CreatedBy: "cactus-app"}
+	if len(opts.Trackers) > 0 {
+		mi.Announce = opts.Trackers[0]
+		mi.AnnounceList = metainfo.AnnounceList{opts.Trackers}
+	}
+
+	mi.InfoBytes, err = bencode.Marshal(info)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode torrent info: %w", err)
+	}
+
+	torrentPath = filepath.Join(e.downloadPath, info.Name+".torrent")
+	f, err := os.Create(torrentPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create %q: %w", torrentPath, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := mi.Write(f); err != nil {
+		return "", "", fmt.Errorf("failed to write %q: %w", torrentPath, err)
+	}
+
+	infoHash := mi.HashInfoBytes()
+	magnet = buildMagnet(infoHash, info.Name, opts.Trackers)
+
+	storageRoot := filepath.Dir(absPath)
+	spec := torrent.TorrentSpecFromMetaInfo(mi)
+	spec.Storage = storage.NewFile(storageRoot)
+
+	t, _, err := e.client.AddTorrentSpec(spec)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to seed %q: %w", absPath, err)
+	}
+
+	e.track(t, trackParams{
+		source:      torrentPath,
+		label:       opts.Label,
+		seed:        true,
+		addedAt:     time.Now(),
+		storageRoot: storageRoot,
+	})
+
+	if err := e.persist(); err != nil {
+		return "", "", err
+	}
+
+	return magnet, torrentPath, nil
+}
+
+// addSeededTorrent re-adds the .torrent file at torrentPath to client
+// with its storage rooted at storageRoot, the same layout CreateAndSeed
+// originally created it with, so a seeded torrent keeps pointing at its
+// actual content after an Engine restart.
+func addSeededTorrent(client *torrent.Client, torrentPath, storageRoot string) (*torrent.Torrent, error) {
+	mi, err := metainfo.LoadFromFile(torrentPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load torrent file %q: %w", torrentPath, err)
+	}
+
+	spec := torrent.TorrentSpecFromMetaInfo(mi)
+	spec.Storage = storage.NewFile(storageRoot)
+
+	t, _, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seed %q: %w", torrentPath, err)
+	}
+	return t, nil
+}
+
+// totalSize sums the size of path, which may be a single file or a
+// directory tree.
+func totalSize(path string, stat os.FileInfo) int64 {
+	if !stat.IsDir() {
+		return stat.Size()
+	}
+
+	var size int64
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+	return size
+}
+
+// buildMagnet assembles a magnet URI from an infohash, display name and
+// tracker list.
+func buildMagnet(infoHash metainfo.Hash, name string, trackers []string) string {
+	var b strings.Builder
+	b.WriteString("magnet:?xt=urn:btih:")
+	b.WriteString(infoHash.HexString())
+
+	if name != "" {
+		b.WriteString("&dn=")
+		b.WriteString(url.QueryEscape(name))
+	}
+
+	for _, tr := range trackers {
+		b.WriteString("&tr=")
+		b.WriteString(url.QueryEscape(tr))
+	}
+
+	return b.String()
+}
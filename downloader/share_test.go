@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func Test_pieceLengthFor(t *testing.T) {
+	cases := []struct {
+		totalSize int64
+		want      int64
+	}{
+		{totalSize: 0, want: 256 << 10},
+		{totalSize: 1 << 20, want: 256 << 10},
+		{totalSize: 1 << 30, want: 1 << 20},
+		{totalSize: 1 << 40, want: 16 << 20}, // caps out rather than growing forever
+	}
+
+	for _, c := range cases {
+		if got := pieceLengthFor(c.totalSize); got != c.want {
+			t.Errorf("pieceLengthFor(%d) = %d, want %d", c.totalSize, got, c.want)
+		}
+	}
+}
+
+func Test_buildMagnet(t *testing.T) {
+	hash := metainfo.NewHashFromHex("3b245504cf5f11bbdbe1201cea6a6bf45aee1bc0")
+
+	magnet := buildMagnet(hash, "ubuntu.iso", []string{"udp://tracker.example:80"})
+
+	if !strings.HasPrefix(magnet, "magnet:?xt=urn:btih:3b245504cf5f11bbdbe1201cea6a6bf45aee1bc0") {
+		t.Fatalf("buildMagnet() = %q, missing expected infohash prefix", magnet)
+	}
+	if !strings.Contains(magnet, "dn=ubuntu.iso") {
+		t.Errorf("buildMagnet() = %q, missing dn param", magnet)
+	}
+	if !strings.Contains(magnet, "tr=udp%3A%2F%2Ftracker.example%3A80") {
+		t.Errorf("buildMagnet() = %q, missing escaped tr param", magnet)
+	}
+}
+
+func Test_buildMagnet_noTrackers(t *testing.T) {
+	hash := metainfo.NewHashFromHex("3b245504cf5f11bbdbe1201cea6a6bf45aee1bc0")
+
+	magnet := buildMagnet(hash, "", nil)
+
+	if strings.Contains(magnet, "&dn=") || strings.Contains(magnet, "&tr=") {
+		t.Errorf("buildMagnet() = %q, should omit dn/tr when unset", magnet)
+	}
+}
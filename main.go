@@ -2,17 +2,26 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/anacrolix/log"
+	"github.com/skip2/go-qrcode"
 	"github.com/tomiok/cactus-app/downloader"
+	"github.com/tomiok/cactus-app/downloader/backend/aria2"
+	"github.com/tomiok/cactus-app/downloader/backend/embedded"
+	"github.com/tomiok/cactus-app/downloader/backend/qbittorrent"
+	"image"
+	_ "image/png"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
@@ -64,8 +73,9 @@ type Result struct {
 	Categories   []int     `json:"Category"`
 	MagnetUri    string    `json:"MagnetUri"`
 
-	IsDownloading bool `json:"-"`
-	IsDownloaded  bool `json:"-"`
+	IsDownloading bool   `json:"-"`
+	IsDownloaded  bool   `json:"-"`
+	InfoHash      string `json:"-"`
 }
 
 // SearchResults is the structure for the API response
@@ -82,6 +92,7 @@ type SearchRequest struct {
 }
 
 const apiURL = "http://localhost:7000/search"
+const downloadDir = "~/Downloads"
 
 func fetchSearchResults(query string) (SearchResults, error) {
 	req := SearchRequest{Query: query}
@@ -116,7 +127,7 @@ func fetchSearchResults(query string) (SearchResults, error) {
 
 	var curatedList SearchResults
 	for _, res := range searchResults.Results {
-		if res.MagnetUri != "" {
+		if res.MagnetUri != "" || res.Link != "" {
 			curatedList.Results = append(curatedList.Results, res)
 		}
 	}
@@ -125,7 +136,314 @@ func fetchSearchResults(query string) (SearchResults, error) {
 	return curatedList, nil
 }
 
+// splitCSV parses a comma-separated entry field into a cleaned list,
+// trimming whitespace and dropping empty entries.
+func splitCSV(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// newShareTab builds the Share tab: pick a file or directory, optionally
+// set trackers, and create + seed a torrent for it.
+func newShareTab(myApp fyne.App, myWindow fyne.Window, engine *downloader.Engine) fyne.CanvasObject {
+	pathLabel := widget.NewLabel("No file or folder selected")
+	pathLabel.Wrapping = fyne.TextWrapWord
+
+	var selectedPath string
+
+	chooseFileButton := widget.NewButtonWithIcon("Choose File", theme.FileIcon(), nil)
+	chooseFolderButton := widget.NewButtonWithIcon("Choose Folder", theme.FolderIcon(), nil)
+
+	chooseFileButton.OnTapped = func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			defer func() {
+				_ = reader.Close()
+			}()
+			selectedPath = reader.URI().Path()
+			pathLabel.SetText(selectedPath)
+		}, myWindow)
+	}
+
+	chooseFolderButton.OnTapped = func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil || uri == nil {
+				return
+			}
+			selectedPath = uri.Path()
+			pathLabel.SetText(selectedPath)
+		}, myWindow)
+	}
+
+	trackersEntry := widget.NewEntry()
+	trackersEntry.SetPlaceHolder("Comma-separated tracker URLs (optional)")
+
+	magnetEntry := widget.NewEntry()
+	magnetEntry.Disable()
+
+	qrImage := canvas.NewImageFromImage(nil)
+	qrImage.FillMode = canvas.ImageFillContain
+	qrImage.SetMinSize(fyne.NewSize(200, 200))
+
+	copyButton := widget.NewButtonWithIcon("Copy Magnet", theme.ContentCopyIcon(), func() {
+		myWindow.Clipboard().SetContent(magnetEntry.Text)
+	})
+	copyButton.Disable()
+
+	seedButton := widget.NewButtonWithIcon("Create && Seed", theme.UploadIcon(), nil)
+	seedButton.Importance = widget.HighImportance
+
+	seedButton.OnTapped = func() {
+		if selectedPath == "" {
+			dialog.ShowInformation("Error", "Choose a file or folder first", myWindow)
+			return
+		}
+
+		// Hashing the content to build the .torrent runs synchronously,
+		// so it's done off the UI goroutine like every other slow
+		// operation in this file.
+		go func() {
+			magnet, torrentPath, err := engine.CreateAndSeed(selectedPath, downloader.SeedOptions{
+				Trackers: splitCSV(trackersEntry.Text),
+			})
+
+			myApp.Driver().DoFromGoroutine(func() {
+				if err != nil {
+					dialog.ShowError(err, myWindow)
+					return
+				}
+
+				magnetEntry.SetText(magnet)
+				copyButton.Enable()
+
+				png, err := qrcode.Encode(magnet, qrcode.Medium, 200)
+				if err == nil {
+					if img, _, decodeErr := image.Decode(bytes.NewReader(png)); decodeErr == nil {
+						qrImage.Image = img
+						qrImage.Refresh()
+					}
+				}
+
+				dialog.ShowInformation("Seeding started", fmt.Sprintf("Wrote %s and started seeding.", torrentPath), myWindow)
+			}, false)
+		}()
+	}
+
+	form := container.NewVBox(
+		container.NewHBox(chooseFileButton, chooseFolderButton),
+		pathLabel,
+		trackersEntry,
+		seedButton,
+		container.NewBorder(nil, nil, nil, copyButton, magnetEntry),
+		qrImage,
+	)
+
+	return container.NewPadded(form)
+}
+
+// backendHolder holds whichever downloader.Backend is currently active,
+// so the Settings tab can switch backends at runtime without every
+// other tab needing its own reference threaded through.
+type backendHolder struct {
+	mu      sync.Mutex
+	backend downloader.Backend
+}
+
+func newBackendHolder(initial downloader.Backend) *backendHolder {
+	return &backendHolder{backend: initial}
+}
+
+func (h *backendHolder) get() downloader.Backend {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.backend
+}
+
+func (h *backendHolder) set(b downloader.Backend) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backend = b
+}
+
+// newSettingsTab builds the Settings tab: choose which downloader.Backend
+// handles Add/Pause/Resume/Remove/List for the rest of the app, pointing
+// Cactus at a remote qBittorrent or Aria2 instance instead of its
+// embedded anacrolix client.
+func newSettingsTab(myApp fyne.App, myWindow fyne.Window, holder *backendHolder, engine *downloader.Engine) fyne.CanvasObject {
+	statusLabel := widget.NewLabel("Using embedded client")
+
+	backendSelect := widget.NewSelect([]string{"Embedded", "qBittorrent", "Aria2"}, nil)
+	backendSelect.SetSelected("Embedded")
+
+	urlEntry := widget.NewEntry()
+	urlEntry.SetPlaceHolder("Backend URL, e.g. http://localhost:8080")
+
+	userEntry := widget.NewEntry()
+	userEntry.SetPlaceHolder("Username (qBittorrent only)")
+
+	secretEntry := widget.NewPasswordEntry()
+	secretEntry.SetPlaceHolder("Password (qBittorrent) or RPC secret (Aria2)")
+
+	connectButton := widget.NewButtonWithIcon("Connect", theme.ConfirmIcon(), nil)
+	connectButton.Importance = widget.HighImportance
+	connectButton.OnTapped = func() {
+		selected := backendSelect.Selected
+		url := urlEntry.Text
+
+		// qbittorrent.New logs into the remote instance over HTTP, so
+		// it's done off the UI goroutine like every other network call
+		// in this file.
+		go func() {
+			switch selected {
+			case "qBittorrent":
+				b, err := qbittorrent.New(qbittorrent.Config{
+					BaseURL:  url,
+					Username: userEntry.Text,
+					Password: secretEntry.Text,
+				})
+				myApp.Driver().DoFromGoroutine(func() {
+					if err != nil {
+						dialog.ShowError(err, myWindow)
+						return
+					}
+					holder.set(b)
+					statusLabel.SetText("Using qBittorrent at " + url)
+				}, false)
+			case "Aria2":
+				b := aria2.New(aria2.Config{RPCURL: url, Secret: secretEntry.Text})
+				myApp.Driver().DoFromGoroutine(func() {
+					holder.set(b)
+					statusLabel.SetText("Using Aria2 at " + url)
+				}, false)
+			default:
+				myApp.Driver().DoFromGoroutine(func() {
+					holder.set(embedded.New(engine))
+					statusLabel.SetText("Using embedded client")
+				}, false)
+			}
+		}()
+	}
+
+	form := container.NewVBox(
+		backendSelect,
+		urlEntry,
+		userEntry,
+		secretEntry,
+		connectButton,
+		statusLabel,
+	)
+
+	return container.NewPadded(form)
+}
+
+// newControlPanelTab builds the Control Panel tab: a list of every
+// torrent the engine is tracking, refreshed from engine.List() on a
+// timer, with pause/resume/remove actions per row.
+func newControlPanelTab(myApp fyne.App, holder *backendHolder) fyne.CanvasObject {
+	var torrentStates []downloader.TorrentState
+
+	torrentList := widget.NewList(
+		func() int {
+			return len(torrentStates)
+		},
+		func() fyne.CanvasObject {
+			nameLabel := widget.NewLabel("Name placeholder")
+			nameLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+			progressLabel := widget.NewLabel("Status placeholder")
+
+			pauseButton := widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), nil)
+			resumeButton := widget.NewButtonWithIcon("Resume", theme.MediaPlayIcon(), nil)
+			removeButton := widget.NewButtonWithIcon("Remove", theme.DeleteIcon(), nil)
+			buttons := container.NewHBox(pauseButton, resumeButton, removeButton)
+
+			content := container.NewVBox(nameLabel, progressLabel)
+
+			return container.NewBorder(nil, nil, nil, buttons, container.NewPadded(content))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			if id >= len(torrentStates) {
+				return
+			}
+			state := torrentStates[id]
+
+			itemContainer := item.(*fyne.Container)
+			buttons := itemContainer.Objects[1].(*fyne.Container)
+			paddedContent := itemContainer.Objects[0].(*fyne.Container)
+			content := paddedContent.Objects[0].(*fyne.Container)
+
+			nameLabel := content.Objects[0].(*widget.Label)
+			progressLabel := content.Objects[1].(*widget.Label)
+			pauseButton := buttons.Objects[0].(*widget.Button)
+			resumeButton := buttons.Objects[1].(*widget.Button)
+			removeButton := buttons.Objects[2].(*widget.Button)
+
+			nameLabel.SetText(state.Name)
+
+			var percentDone float64
+			if state.TotalBytes > 0 {
+				percentDone = float64(state.BytesCompleted) / float64(state.TotalBytes) * 100
+			}
+			progressLabel.SetText(fmt.Sprintf("%.1f%% - %s - %d peers", percentDone, state.Status, state.Seeders))
+
+			infoHash := state.InfoHash
+			pauseButton.OnTapped = func() {
+				if err := holder.get().Pause(infoHash); err != nil {
+					log.Printf("cannot pause torrent %s \n", err)
+				}
+			}
+			resumeButton.OnTapped = func() {
+				if err := holder.get().Resume(infoHash); err != nil {
+					log.Printf("cannot resume torrent %s \n", err)
+				}
+			}
+			removeButton.OnTapped = func() {
+				if err := holder.get().Remove(infoHash, false); err != nil {
+					log.Printf("cannot remove torrent %s \n", err)
+				}
+			}
+
+			if state.Status == downloader.StatusPaused {
+				pauseButton.Disable()
+				resumeButton.Enable()
+			} else {
+				pauseButton.Enable()
+				resumeButton.Disable()
+			}
+		},
+	)
+
+	// Poll the active backend for a fresh snapshot instead of reacting
+	// to every per-torrent Progress event, since the whole list needs
+	// to be re-sorted/re-sized as torrents are added or removed, and
+	// since the active backend itself can change at any time.
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			states := holder.get().List()
+			myApp.Driver().DoFromGoroutine(func() {
+				torrentStates = states
+				torrentList.Refresh()
+			}, false)
+		}
+	}()
+
+	return container.NewPadded(torrentList)
+}
+
 func main() {
+	webseedsFlag := flag.String("webseeds", "", "comma-separated webseed URLs used as a fallback when a torrent has no peers")
+	flag.Parse()
+
 	myApp := app.New()
 
 	myApp.Settings().SetTheme(&LargerTheme{Theme: theme.DefaultTheme()})
@@ -133,6 +451,25 @@ func main() {
 	myWindow := myApp.NewWindow("Cactus app")
 	myWindow.Resize(fyne.NewSize(1000, 700)) // Larger default window size
 
+	engine, err := downloader.NewEngine(downloadDir)
+	if err != nil {
+		log.Printf("cannot start download engine %s \n", err)
+		return
+	}
+	defer engine.Close()
+
+	// The active Backend starts out as the embedded client; the
+	// Settings tab can point it at a remote qBittorrent or Aria2
+	// instance instead.
+	holder := newBackendHolder(embedded.New(engine))
+
+	// Webseeds (BEP-19) settings field, seeded from --webseeds; activated
+	// as a fallback source when a download finds no peers within
+	// downloader.webSeedGracePeriod.
+	webseedsEntry := widget.NewEntry()
+	webseedsEntry.SetPlaceHolder("Webseed URLs, comma-separated (fallback if no peers)")
+	webseedsEntry.SetText(*webseedsFlag)
+
 	var searchResult SearchResults
 
 	// Create status bar at the bottom - now with larger text
@@ -233,53 +570,42 @@ func main() {
 			// Update status
 			statusLabel.SetText(fmt.Sprintf("Downloading: %s", result.Title))
 
-			// Simulate download process with goroutine
+			// Prefer the magnet URI; fall back to the .torrent link when
+			// the indexer didn't provide one. Indexer Link fields are
+			// plain http(s):// URLs to a metainfo file, so tag them with
+			// the torrent+ scheme Add/Download expect.
+			source := result.MagnetUri
+			if source == "" {
+				source = "torrent+" + result.Link
+			}
+
 			go func() {
 				// Show download dialog
 				dialog.ShowInformation(
 					"Download Started",
-					fmt.Sprintf("Starting download for: %s\n\nMagnet link: %s",
-						result.Title, result.MagnetUri),
+					fmt.Sprintf("Starting download for: %s\n\nSource: %s",
+						result.Title, source),
 					myWindow,
 				)
 
-				td, err := downloader.NewTorrentDownloader("~/Downloads")
-				if err != nil {
-					log.Printf("cannot create directory %s \n", err)
-					return
-				}
-
-				title, err := td.DownloadFromMagnet(context.Background(), result.MagnetUri, func(info downloader.ProgressInfo) {
-					// Format download speed
-					var speedStr string
-					if info.DownloadSpeed < 1024 {
-						speedStr = fmt.Sprintf("%.2f B/s", info.DownloadSpeed)
-					} else if info.DownloadSpeed < 1024*1024 {
-						speedStr = fmt.Sprintf("%.2f KB/s", info.DownloadSpeed/1024)
-					} else {
-						speedStr = fmt.Sprintf("%.2f MB/s", info.DownloadSpeed/(1024*1024))
-					}
-
-					fmt.Printf("\rProgress: %.2f%% (%.2f MB/%.2f MB) - Peers: %d - Speed: %s",
-						info.PercentDone,
-						float64(info.BytesCompleted)/(1024*1024),
-						float64(info.TotalBytes)/(1024*1024),
-						info.PeersConnected,
-						speedStr,
-					)
+				infoHash, err := holder.get().Add(source, downloader.AddOptions{
+					WebSeeds: splitCSV(webseedsEntry.Text),
 				})
-
 				if err != nil {
-					log.Printf("cannot complete download %s \n", err)
+					log.Printf("cannot start download %s \n", err)
+					myApp.Driver().DoFromGoroutine(func() {
+						searchResult.Results[id].IsDownloading = false
+						resultList.Refresh()
+						statusLabel.SetText("Download failed: " + result.Title)
+					}, false)
 					return
 				}
 
-				// Use the safe approach to update UI from a goroutine in Fyne
+				// The active backend now owns this transfer; the
+				// reconciliation loop below reflects its progress.
 				myApp.Driver().DoFromGoroutine(func() {
-					searchResult.Results[id].IsDownloading = false
-					searchResult.Results[id].IsDownloaded = true
+					searchResult.Results[id].InfoHash = infoHash
 					resultList.Refresh()
-					statusLabel.SetText("Download complete: " + title)
 				}, false)
 			}()
 		}
@@ -288,6 +614,35 @@ func main() {
 	// Now set the update function
 	resultList.UpdateItem = updateFunc
 
+	// Reconcile search result rows against the active backend's state
+	// as torrents progress, instead of a goroutine per in-flight
+	// download. Polling the active backend directly (rather than a
+	// long-lived Progress channel) means a backend switch in the
+	// Settings tab takes effect here immediately too.
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			states := holder.get().List()
+			myApp.Driver().DoFromGoroutine(func() {
+				for _, state := range states {
+					for i := range searchResult.Results {
+						if searchResult.Results[i].InfoHash != state.InfoHash {
+							continue
+						}
+						searchResult.Results[i].IsDownloaded = state.Status == downloader.StatusComplete
+						searchResult.Results[i].IsDownloading = !searchResult.Results[i].IsDownloaded
+						if searchResult.Results[i].IsDownloaded {
+							statusLabel.SetText("Download complete: " + state.Name)
+						}
+						resultList.Refresh()
+					}
+				}
+			}, false)
+		}
+	}()
+
 	// Create search interface
 	searchEntry := widget.NewEntry()
 	searchEntry.SetPlaceHolder("Enter search terms...")
@@ -344,9 +699,12 @@ func main() {
 
 	// Create search container with padding
 	searchContainer := container.NewPadded(
-		container.NewBorder(
-			nil, nil, nil, searchButton,
-			searchEntry,
+		container.NewVBox(
+			container.NewBorder(
+				nil, nil, nil, searchButton,
+				searchEntry,
+			),
+			webseedsEntry,
 		),
 	)
 
@@ -363,21 +721,16 @@ func main() {
 		container.NewPadded(resultList),
 	)
 
-	// Create other tabs with larger, centered text
-	shareLabel := widget.NewLabel("Share functionality will be implemented in a future version")
-	shareLabel.TextStyle = fyne.TextStyle{Bold: true}
-
-	controlLabel := widget.NewLabel("Control Panel will be implemented in a future version")
-	controlLabel.TextStyle = fyne.TextStyle{Bold: true}
-
-	shareTab := container.NewCenter(shareLabel)
-	controlTab := container.NewCenter(controlLabel)
+	shareTab := newShareTab(myApp, myWindow, engine)
+	controlTab := newControlPanelTab(myApp, holder)
+	settingsTab := newSettingsTab(myApp, myWindow, holder, engine)
 
 	// Create tabs container
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Search", searchTab),
 		container.NewTabItem("Share", shareTab),
 		container.NewTabItem("Control Panel", controlTab),
+		container.NewTabItem("Settings", settingsTab),
 	)
 
 	// Make tabs more prominent